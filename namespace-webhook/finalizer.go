@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// finalBackupFinalizer is a backstop for namespace deletions this webhook
+// never got an admission call for (e.g. downtime combined with
+// FailurePolicy: Ignore). The primary defense is ServerNamespaceBackup
+// itself denying the Delete admission until finalBackupReady, since a
+// finalizer only blocks removal of the (already-empty) Namespace object, not
+// the namespace controller purging its contents.
+const finalBackupFinalizer = "backup.policy/final-backup"
+
+// annotationSkipFinalBackup lets an operator opt a namespace out of the
+// pre-delete backup entirely, e.g. for namespaces already covered elsewhere.
+const annotationSkipFinalBackup = "backup.policy/skip-final-backup"
+
+// finalBackupTimeout bounds how long the finalizer controller waits for the
+// final backup to reach Completed before removing the finalizer anyway, so a
+// stuck or failed backup can never block namespace deletion forever.
+var finalBackupTimeout = 30 * time.Minute
+
+// veleroBackupGVK is the Kind-based identifier for Velero Backups, used by
+// the finalizer controller's controller-runtime client.
+var veleroBackupGVK = schema.GroupVersionKind{Group: "velero.io", Version: "v1", Kind: "Backup"}
+
+// finalBackupName returns the deterministic name of the one pre-delete backup
+// triggered for namespaceName, so retried Delete admission calls don't
+// trigger duplicate backups.
+func finalBackupName(namespaceName string) string {
+	return fmt.Sprintf("%s-final-backup", namespaceName)
+}
+
+// hasFinalizer reports whether name is present in finalizers.
+func hasFinalizer(finalizers []string, name string) bool {
+	for _, f := range finalizers {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// finalBackupReady reports whether namespaceName's final pre-delete backup
+// has reached Completed, or has been running longer than finalBackupTimeout
+// (in which case deletion is let through regardless, so a stuck or failed
+// backup can never block namespace deletion forever). It reports false, with
+// no error, if the backup hasn't been triggered yet.
+func finalBackupReady(ctx context.Context, dynamicClient dynamic.Interface, namespaceName string, logger *logrus.Entry) (bool, error) {
+	backup, err := dynamicClient.Resource(veleroBackupResource).Namespace(veleroNamespace).Get(ctx, finalBackupName(namespaceName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	phase, _, _ := unstructured.NestedString(backup.Object, "status", "phase")
+	if phase == "Completed" {
+		return true, nil
+	}
+	if time.Since(backup.GetCreationTimestamp().Time) > finalBackupTimeout {
+		logger.WithFields(logrus.Fields{"namespace": namespaceName, "backup": backup.GetName()}).Warn("Final backup timed out, allowing namespace deletion to proceed")
+		return true, nil
+	}
+	return false, nil
+}
+
+// triggerFinalBackup creates the final, pre-delete Velero backup for
+// namespaceName if one hasn't already been triggered.
+func triggerFinalBackup(ctx context.Context, dynamicClient dynamic.Interface, eventsClient kubernetes.Interface, namespaceName string, policy BackupPolicySpec, logger *logrus.Entry) {
+	backupName := finalBackupName(namespaceName)
+
+	if _, err := dynamicClient.Resource(veleroBackupResource).Namespace(veleroNamespace).Get(ctx, backupName, metav1.GetOptions{}); err == nil {
+		return
+	}
+
+	veleroBackup := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "velero.io/v1",
+			"kind":       "Backup",
+			"metadata": map[string]interface{}{
+				"name":      backupName,
+				"namespace": veleroNamespace,
+			},
+			"spec": backupTemplate(namespaceName, policy),
+		},
+	}
+
+	logger.Info(fmt.Sprintf("Triggering final pre-delete Velero backup %s", backupName))
+	if _, err := dynamicClient.Resource(veleroBackupResource).Namespace(veleroNamespace).Create(ctx, veleroBackup, metav1.CreateOptions{}); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to trigger final Velero backup")
+		emitNamespaceEvent(ctx, eventsClient, veleroNamespace, namespaceName, "FinalBackupFailed", fmt.Sprintf("Failed to trigger final backup %s: %v", backupName, err), corev1.EventTypeWarning, logger)
+		return
+	}
+	emitNamespaceEvent(ctx, eventsClient, veleroNamespace, namespaceName, "FinalBackupTriggered", fmt.Sprintf("Triggered final backup %s before namespace deletion", backupName), corev1.EventTypeNormal, logger)
+}
+
+// buildFinalBackupFinalizerPatch returns the JSON patch operations that add
+// finalBackupFinalizer to ns, or nil if no patch is needed: either the
+// namespace opted out via backup.policy/skip-final-backup, or the finalizer
+// is already present from an earlier Create/Update admission call.
+func buildFinalBackupFinalizerPatch(ns corev1.Namespace) []map[string]interface{} {
+	if skip, _ := strconv.ParseBool(ns.Annotations[annotationSkipFinalBackup]); skip {
+		return nil
+	}
+	if hasFinalizer(ns.Finalizers, finalBackupFinalizer) {
+		return nil
+	}
+	if len(ns.Finalizers) == 0 {
+		return []map[string]interface{}{
+			{"op": "add", "path": "/metadata/finalizers", "value": []string{finalBackupFinalizer}},
+		}
+	}
+	return []map[string]interface{}{
+		{"op": "add", "path": "/metadata/finalizers/-", "value": finalBackupFinalizer},
+	}
+}
+
+// startFinalizerController watches Namespaces and removes finalBackupFinalizer
+// once that namespace's final backup reaches Completed, or once
+// finalBackupTimeout has elapsed since the namespace was marked for deletion,
+// whichever comes first. It blocks, so callers should run it in a goroutine.
+func startFinalizerController() error {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return fmt.Errorf("get in-cluster config: %w", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return fmt.Errorf("register core scheme: %w", err)
+	}
+
+	watchClient, err := client.NewWithWatch(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("create watch client: %w", err)
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"component": "finalizer"})
+	ctx := context.Background()
+
+	watcher, err := watchClient.Watch(ctx, &corev1.NamespaceList{})
+	if err != nil {
+		return fmt.Errorf("watch namespaces: %w", err)
+	}
+	defer watcher.Stop()
+
+	reconcileAllFinalizingNamespaces(ctx, watchClient, logger)
+
+	ticker := time.NewTicker(reconcileResyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return fmt.Errorf("namespace watch channel closed")
+			}
+			ns, ok := event.Object.(*corev1.Namespace)
+			if !ok || ns.DeletionTimestamp == nil || !hasFinalizer(ns.Finalizers, finalBackupFinalizer) {
+				continue
+			}
+			reconcileFinalizingNamespace(ctx, watchClient, ns, logger)
+		case <-ticker.C:
+			reconcileAllFinalizingNamespaces(ctx, watchClient, logger)
+		}
+	}
+}
+
+// reconcileAllFinalizingNamespaces re-scans every namespace for ones stuck
+// behind finalBackupFinalizer, catching any missed while this controller was
+// down.
+func reconcileAllFinalizingNamespaces(ctx context.Context, c client.WithWatch, logger *logrus.Entry) {
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to list namespaces for finalizer reconciliation")
+		return
+	}
+
+	for i := range namespaces.Items {
+		ns := &namespaces.Items[i]
+		if ns.DeletionTimestamp != nil && hasFinalizer(ns.Finalizers, finalBackupFinalizer) {
+			reconcileFinalizingNamespace(ctx, c, ns, logger)
+		}
+	}
+}
+
+// reconcileFinalizingNamespace removes finalBackupFinalizer from ns once its
+// final backup has completed or finalBackupTimeout has elapsed.
+func reconcileFinalizingNamespace(ctx context.Context, c client.WithWatch, ns *corev1.Namespace, logger *logrus.Entry) {
+	logger = logger.WithFields(logrus.Fields{"namespace": ns.Name})
+
+	if time.Since(ns.DeletionTimestamp.Time) > finalBackupTimeout {
+		logger.Warn("Final backup timed out, removing finalizer to let namespace deletion proceed")
+		removeFinalBackupFinalizer(ctx, c, ns, logger)
+		return
+	}
+
+	backup := &unstructured.Unstructured{}
+	backup.SetGroupVersionKind(veleroBackupGVK)
+	err := c.Get(ctx, client.ObjectKey{Name: finalBackupName(ns.Name), Namespace: veleroNamespace}, backup)
+	if apierrors.IsNotFound(err) {
+		// No final backup was triggered (e.g. skip-final-backup was set after
+		// the finalizer was already added): nothing to wait for.
+		removeFinalBackupFinalizer(ctx, c, ns, logger)
+		return
+	}
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to get final backup status")
+		return
+	}
+
+	phase, _, _ := unstructured.NestedString(backup.Object, "status", "phase")
+	if phase != "Completed" {
+		return
+	}
+
+	logger.Info("Final backup completed, removing finalizer")
+	removeFinalBackupFinalizer(ctx, c, ns, logger)
+}
+
+// removeFinalBackupFinalizer drops finalBackupFinalizer from ns and updates it.
+func removeFinalBackupFinalizer(ctx context.Context, c client.WithWatch, ns *corev1.Namespace, logger *logrus.Entry) {
+	var latest corev1.Namespace
+	if err := c.Get(ctx, client.ObjectKey{Name: ns.Name}, &latest); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Failed to get namespace before removing finalizer")
+		}
+		return
+	}
+
+	remaining := make([]string, 0, len(latest.Finalizers))
+	for _, f := range latest.Finalizers {
+		if f != finalBackupFinalizer {
+			remaining = append(remaining, f)
+		}
+	}
+	latest.Finalizers = remaining
+
+	if err := c.Update(ctx, &latest); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to remove final-backup finalizer")
+	}
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
@@ -31,14 +34,48 @@ var (
 	backupSuffix             string = "backup"    // Backup sufix
 	logFormat                string = "text"      // Log format (text or json)
 	logLevel                 string = "info"      // Log level (debug, info, warn, error, fatal, panic)
+	enableMaintenanceJobs    bool                 // --enable-maintenance-jobs - provision Velero repository maintenance CronJobs
 )
 
 func main() {
+	flag.BoolVar(&enableMaintenanceJobs, "enable-maintenance-jobs", false, "Provision and reconcile Velero BackupRepository maintenance CronJobs")
+	flag.StringVar(&selectorFlag, "selector", "", "Kubernetes label selector (e.g. \"backup/enabled=true,tier!=ephemeral\") a namespace must match to be a backup target")
+	flag.StringVar(&celSelectorFlag, "cel-selector", "", "CEL expression evaluated against namespace/oldNamespace/operation to decide backup targets")
+	flag.Parse()
+
 	setEnv() // Get and set environment variables
 
-	// Set up HTTP handlers for the validation and health endpoints
+	loadSelector(logrus.WithFields(logrus.Fields{"component": "selector"}))
+
+	// Start the watch-based reconciler alongside the webhook so that Schedules
+	// stay in sync with namespace labels even when the webhook missed events
+	// (e.g. while it was down).
+	go func() {
+		if err := startScheduleReconciler(); err != nil {
+			logrus.WithFields(logrus.Fields{"error": err}).Error("Schedule reconciler exited")
+		}
+	}()
+
+	// Start the repository maintenance job controller, if enabled.
+	go func() {
+		if err := startMaintenanceJobController(); err != nil {
+			logrus.WithFields(logrus.Fields{"error": err}).Error("Maintenance job controller exited")
+		}
+	}()
+
+	// Start the finalizer controller that releases finalBackupFinalizer once
+	// a namespace's final pre-delete backup completes (or times out).
+	go func() {
+		if err := startFinalizerController(); err != nil {
+			logrus.WithFields(logrus.Fields{"error": err}).Error("Finalizer controller exited")
+		}
+	}()
+
+	// Set up HTTP handlers for validation, health and metrics endpoints
 	http.HandleFunc("/validate", ServerNamespaceBackup)
-	http.HandleFunc("/health", ServerHealth)
+	http.HandleFunc("/livez", ServerLivez)
+	http.HandleFunc("/readyz", ServerReadyz)
+	http.Handle("/metrics", metricsHandler())
 
 	// Start the HTTPS server with TLS certificates
 	cert := "/etc/admission-webhook/tls/tls.crt"
@@ -51,6 +88,12 @@ func main() {
 func ServerNamespaceBackup(w http.ResponseWriter, r *http.Request) {
 	logger := logrus.WithFields(logrus.Fields{"uri": r.RequestURI})
 
+	operation := "unknown"
+	result := "error"
+	defer func() {
+		admissionRequestsTotal.WithLabelValues(operation, result).Inc()
+	}()
+
 	// Parse the admission request
 	admissionReview, err := parseRequest(*r)
 	if err != nil {
@@ -58,6 +101,7 @@ func ServerNamespaceBackup(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	operation = string(admissionReview.Request.Operation)
 
 	oldNamespace := corev1.Namespace{}
 	namespace := corev1.Namespace{}
@@ -115,45 +159,116 @@ func ServerNamespaceBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract labels to determine whether to create, update, or delete Kubevela target
-	targetName, targetKey := namespace.Labels["namespace.oam.dev/target"]
-	runtime, runtimeKey := namespace.Labels["usage.oam.dev/runtime"]
-	oldTargetName, oldTargetKey := oldNamespace.Labels["namespace.oam.dev/target"]
-	oldRuntime, oldRuntimeKey := oldNamespace.Labels["usage.oam.dev/runtime"]
+	// Create a typed clientset used only to emit audit Events on the Namespace.
+	eventsClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to create events client")
+		http.Error(w, fmt.Sprintf("Could not create events client: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Determine whether the namespace is (or was) a backup target, per the
+	// configured selector (label selector, CEL expression, or the legacy
+	// hard-coded Kubevela OAM labels if nothing is configured).
+	adminOperation := string(admissionReview.Request.Operation)
+	isTarget := activeSelector.Matches(namespace, oldNamespace, adminOperation)
+	wasTarget := activeSelector.MatchedBefore(namespace, oldNamespace, adminOperation)
+
+	var finalizerPatch []map[string]interface{}
+	var denyReason string
 
 	switch admissionReview.Request.Operation {
 	case admissionv1.Create:
 		// Create Velero schedule and backup if the namespace is restored
-		if targetKey && targetName != "" && runtimeKey && runtime == "target" {
-			createVeleroSchedule(*r, dynamicClient, namespace.Name, logger)
-			createVeleroBackup(*r, dynamicClient, namespace.Name, logger)
+		if isTarget {
+			policy := resolveBackupPolicy(r.Context(), dynamicClient, namespace.Name, namespace.Annotations, logger)
+			createVeleroSchedule(*r, dynamicClient, eventsClient, namespace.Name, policy, logger)
+			createVeleroBackup(*r, dynamicClient, eventsClient, namespace.Name, policy, logger)
+
+			// Stamp finalBackupFinalizer on while we can still mutate the
+			// object: the API server ignores patches on DELETE, so this is
+			// the only point the finalizer can actually be written before
+			// the namespace is later torn down.
+			finalizerPatch = buildFinalBackupFinalizerPatch(namespace)
 		}
 	case admissionv1.Update:
 		// Create Velero schedule and backup if the namespace is updated to a target, or delete if no longer a target
-		if targetKey && targetName != "" && runtimeKey && runtime == "target" && (!oldTargetKey || oldTargetName == "" || !oldRuntimeKey || oldRuntime == "") {
-			createVeleroSchedule(*r, dynamicClient, namespace.Name, logger)
-			createVeleroBackup(*r, dynamicClient, namespace.Name, logger)
-		} else if (!targetKey || targetName == "" || !runtimeKey || runtime != "target") && oldTargetKey && oldTargetName != "" && oldRuntimeKey && oldRuntime == "target" {
-			deleteVeleroSchedule(*r, dynamicClient, namespace.Name, logger)
+		if isTarget && !wasTarget {
+			policy := resolveBackupPolicy(r.Context(), dynamicClient, namespace.Name, namespace.Annotations, logger)
+			createVeleroSchedule(*r, dynamicClient, eventsClient, namespace.Name, policy, logger)
+			createVeleroBackup(*r, dynamicClient, eventsClient, namespace.Name, policy, logger)
+		} else if !isTarget && wasTarget {
+			deleteVeleroSchedule(*r, dynamicClient, eventsClient, namespace.Name, namespace.Name, logger)
+		}
+
+		// Backfill the finalizer on every Update of a current target, not
+		// just the transition into being one: it covers namespaces that
+		// became targets while this webhook was down for the Create call.
+		if isTarget {
+			finalizerPatch = buildFinalBackupFinalizerPatch(namespace)
 		}
 
 	case admissionv1.Delete:
-		// Delete Velero schedule if the namespace is deleted and was a target
-		if oldTargetKey && oldTargetName != "" && oldRuntimeKey && oldRuntime == "target" {
-			deleteVeleroSchedule(*r, dynamicClient, oldNamespace.Name, logger)
+		// A finalizer only blocks removal of the (now-empty) Namespace
+		// object; it does not hold back the namespace controller, which
+		// starts purging the namespace's contents as soon as deletion is
+		// admitted. So the final backup has to be awaited here, before the
+		// deletion itself is allowed, not merely delayed afterwards:
+		// triggerFinalBackup's async Backup (CSI snapshots in particular)
+		// would otherwise race the namespace controller tearing down the
+		// very objects it is backing up.
+		if wasTarget {
+			skipFinalBackup, _ := strconv.ParseBool(oldNamespace.Annotations[annotationSkipFinalBackup])
+			switch {
+			case skipFinalBackup:
+				deleteVeleroSchedule(*r, dynamicClient, eventsClient, oldNamespace.Name, veleroNamespace, logger)
+			default:
+				ready, err := finalBackupReady(r.Context(), dynamicClient, oldNamespace.Name, logger)
+				if err != nil {
+					logger.WithFields(logrus.Fields{"error": err}).Error("Failed to check final backup status, allowing namespace deletion")
+					deleteVeleroSchedule(*r, dynamicClient, eventsClient, oldNamespace.Name, veleroNamespace, logger)
+				} else if ready {
+					deleteVeleroSchedule(*r, dynamicClient, eventsClient, oldNamespace.Name, veleroNamespace, logger)
+				} else {
+					policy := resolveBackupPolicy(r.Context(), dynamicClient, oldNamespace.Name, oldNamespace.Annotations, logger)
+					triggerFinalBackup(r.Context(), dynamicClient, eventsClient, oldNamespace.Name, policy, logger)
+					denyReason = fmt.Sprintf("waiting for final pre-delete backup %s to complete before allowing namespace deletion (will proceed regardless after %s); retry the delete shortly", finalBackupName(oldNamespace.Name), finalBackupTimeout)
+				}
+			}
 		}
 	}
 
+	admissionResponse := &admissionv1.AdmissionResponse{
+		UID:     admissionReview.Request.UID,
+		Allowed: denyReason == "",
+	}
+	if denyReason != "" {
+		admissionResponse.Result = &metav1.Status{Message: denyReason}
+	}
+
+	// On Create/Update, patch in finalBackupFinalizer so the namespace
+	// survives until the final backup completes (see reconcileFinalizingNamespace),
+	// as a backstop for Delete admission requests this webhook never saw
+	// (e.g. while it was down and FailurePolicy was Ignore).
+	if finalizerPatch != nil {
+		patchBytes, err := json.Marshal(finalizerPatch)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Failed to marshal finalizer patch")
+			http.Error(w, fmt.Sprintf("Could not marshal finalizer patch: %v", err), http.StatusInternalServerError)
+			return
+		}
+		patchType := admissionv1.PatchTypeJSONPatch
+		admissionResponse.Patch = patchBytes
+		admissionResponse.PatchType = &patchType
+	}
+
 	// Respond to the admission request
 	response := admissionv1.AdmissionReview{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "admission.k8s.io/v1",
 			Kind:       "AdmissionReview",
 		},
-		Response: &admissionv1.AdmissionResponse{
-			UID:     admissionReview.Request.UID,
-			Allowed: true,
-		},
+		Response: admissionResponse,
 	}
 
 	// Marshal the response into JSON and write it to the response writer
@@ -164,19 +279,38 @@ func ServerNamespaceBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if denyReason != "" {
+		result = "denied"
+	} else {
+		result = "allowed"
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(respBytes)
 }
 
-// createVeleroSchedule creates a Velero schedule for backing up the given namespace.
-func createVeleroSchedule(r http.Request, client dynamic.Interface, namespaceName string, logger *logrus.Entry) {
-	scheduleName := fmt.Sprintf("%s-backup", namespaceName)
+// veleroScheduleResource and veleroBackupResource identify the Velero Schedule
+// and Backup CRDs this webhook creates and deletes.
+var veleroScheduleResource = schema.GroupVersionResource{
+	Group:    "velero.io",
+	Version:  "v1",
+	Resource: "schedules",
+}
 
-	veleroScheduleResource := schema.GroupVersionResource{
-		Group:    "velero.io",
-		Version:  "v1",
-		Resource: "schedules",
-	}
+var veleroBackupResource = schema.GroupVersionResource{
+	Group:    "velero.io",
+	Version:  "v1",
+	Resource: "backups",
+}
+
+// scheduleGVR returns the GroupVersionResource used to address Velero Schedules.
+func scheduleGVR() schema.GroupVersionResource {
+	return veleroScheduleResource
+}
+
+// createVeleroSchedule creates a Velero schedule for backing up the given namespace,
+// using the resolved BackupPolicy to fill in the schedule cron and backup template.
+func createVeleroSchedule(r http.Request, client dynamic.Interface, eventsClient kubernetes.Interface, namespaceName string, policy BackupPolicySpec, logger *logrus.Entry) {
+	scheduleName := fmt.Sprintf("%s-backup", namespaceName)
 
 	// Check if the schedule already exists
 	_, err := client.Resource(veleroScheduleResource).Namespace(veleroNamespace).Get(r.Context(), scheduleName, metav1.GetOptions{})
@@ -194,15 +328,9 @@ func createVeleroSchedule(r http.Request, client dynamic.Interface, namespaceNam
 				"namespace": veleroNamespace,
 			},
 			"spec": map[string]interface{}{
-				"schedule":                   cronExpression,
+				"schedule":                   policy.Schedule,
 				"useOwnerReferencesInBackup": false,
-				"template": map[string]interface{}{
-					"csiSnapshotTimeout":       csiSnapshotTimeout,
-					"includedNamespaces":       []string{namespaceName},
-					"storageLocation":          storageLocation,
-					"ttl":                      backupTTL,
-					"defaultVolumesToFsBackup": defaultVolumesToFsBackup,
-				},
+				"template":                   backupTemplate(namespaceName, policy),
 			},
 		},
 	}
@@ -212,22 +340,21 @@ func createVeleroSchedule(r http.Request, client dynamic.Interface, namespaceNam
 	_, err = client.Resource(veleroScheduleResource).Namespace(veleroNamespace).Create(r.Context(), veleroSchedule, metav1.CreateOptions{})
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to create Velero schedule")
+		scheduleOperationsTotal.WithLabelValues("create", "error").Inc()
+		emitNamespaceEvent(r.Context(), eventsClient, namespaceName, namespaceName, "ScheduleCreationFailed", fmt.Sprintf("Failed to create Velero schedule %s: %v", scheduleName, err), corev1.EventTypeWarning, logger)
 	} else {
 		logger.Info("Velero schedule created successfully")
+		scheduleOperationsTotal.WithLabelValues("create", "success").Inc()
+		emitNamespaceEvent(r.Context(), eventsClient, namespaceName, namespaceName, "ScheduleCreated", fmt.Sprintf("Created Velero schedule %s", scheduleName), corev1.EventTypeNormal, logger)
 	}
 }
 
-// createVeleroBackup creates an instant Velero backup for the given namespace.
-func createVeleroBackup(r http.Request, client dynamic.Interface, namespaceName string, logger *logrus.Entry) {
+// createVeleroBackup creates an instant Velero backup for the given namespace,
+// using the resolved BackupPolicy to fill in the backup spec.
+func createVeleroBackup(r http.Request, client dynamic.Interface, eventsClient kubernetes.Interface, namespaceName string, policy BackupPolicySpec, logger *logrus.Entry) {
 	scheduleName := fmt.Sprintf("%s-backup", namespaceName)
 	backupName := fmt.Sprintf("%s-%s", scheduleName, time.Now().Format("20060102150405"))
 
-	veleroBackupResource := schema.GroupVersionResource{
-		Group:    "velero.io",
-		Version:  "v1",
-		Resource: "backups",
-	}
-
 	// Define the Velero backup object
 	veleroBackup := &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -237,42 +364,42 @@ func createVeleroBackup(r http.Request, client dynamic.Interface, namespaceName
 				"name":      backupName,
 				"namespace": veleroNamespace,
 			},
-			"spec": map[string]interface{}{
-				"csiSnapshotTimeout":       csiSnapshotTimeout,
-				"includedNamespaces":       []string{namespaceName},
-				"storageLocation":          storageLocation,
-				"ttl":                      backupTTL,
-				"defaultVolumesToFsBackup": defaultVolumesToFsBackup,
-			},
+			"spec": backupTemplate(namespaceName, policy),
 		},
 	}
 
 	// Create the Velero backup
 	logger.Info(fmt.Sprintf("Creating Velero backup %s", backupName))
+	start := time.Now()
 	_, err := client.Resource(veleroBackupResource).Namespace(veleroNamespace).Create(r.Context(), veleroBackup, metav1.CreateOptions{})
+	backupCreateDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to create Velero backup")
+		emitNamespaceEvent(r.Context(), eventsClient, namespaceName, namespaceName, "BackupTriggerFailed", fmt.Sprintf("Failed to create Velero backup %s: %v", backupName, err), corev1.EventTypeWarning, logger)
 	} else {
 		logger.Info("Velero backup created successfully")
+		emitNamespaceEvent(r.Context(), eventsClient, namespaceName, namespaceName, "BackupTriggered", fmt.Sprintf("Created Velero backup %s", backupName), corev1.EventTypeNormal, logger)
 	}
 }
 
-// deleteVeleroSchedule deletes a Velero schedule associated with the given namespace.
-func deleteVeleroSchedule(r http.Request, client dynamic.Interface, namespaceName string, logger *logrus.Entry) {
+// deleteVeleroSchedule deletes a Velero schedule associated with the given
+// namespace. eventNamespace is where the audit Event is filed: the target
+// namespace itself when it isn't terminating, or veleroNamespace when it is
+// (see emitNamespaceEvent).
+func deleteVeleroSchedule(r http.Request, client dynamic.Interface, eventsClient kubernetes.Interface, namespaceName, eventNamespace string, logger *logrus.Entry) {
 	scheduleName := fmt.Sprintf("%s-backup", namespaceName)
-	veleroScheduleResource := schema.GroupVersionResource{
-		Group:    "velero.io",
-		Version:  "v1",
-		Resource: "schedules",
-	}
 
 	// Attempt to delete the Velero schedule
 	logger.Info(fmt.Sprintf("Deleting Velero schedule %s", scheduleName))
 	err := client.Resource(veleroScheduleResource).Namespace(veleroNamespace).Delete(r.Context(), scheduleName, metav1.DeleteOptions{})
 	if err != nil {
 		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to delete Velero schedule")
+		scheduleOperationsTotal.WithLabelValues("delete", "error").Inc()
+		emitNamespaceEvent(r.Context(), eventsClient, eventNamespace, namespaceName, "ScheduleDeletionFailed", fmt.Sprintf("Failed to delete Velero schedule %s: %v", scheduleName, err), corev1.EventTypeWarning, logger)
 	} else {
 		logger.Info("Velero schedule deleted successfully")
+		scheduleOperationsTotal.WithLabelValues("delete", "success").Inc()
+		emitNamespaceEvent(r.Context(), eventsClient, eventNamespace, namespaceName, "ScheduleDeleted", fmt.Sprintf("Deleted Velero schedule %s", scheduleName), corev1.EventTypeNormal, logger)
 	}
 }
 
@@ -326,20 +453,81 @@ func setEnv() {
 
 	backupSuffix = getEnv("BACKUP_SUFFIX", backupSuffix)
 
+	reconcileResyncPeriodEnv := getEnv("RECONCILE_RESYNC_PERIOD", reconcileResyncPeriod.String())
+	if d, err := time.ParseDuration(reconcileResyncPeriodEnv); err == nil {
+		reconcileResyncPeriod = d
+	} else {
+		logger.WithFields(logrus.Fields{"error": err, "value": reconcileResyncPeriodEnv}).Error("Invalid RECONCILE_RESYNC_PERIOD, keeping default")
+	}
+
+	maintenanceCron = getEnv("MAINTENANCE_CRON", maintenanceCron)
+	maintenanceImage = getEnv("MAINTENANCE_IMAGE", maintenanceImage)
+	maintenanceServiceAccount = getEnv("MAINTENANCE_SERVICE_ACCOUNT", maintenanceServiceAccount)
+	maintenanceCredentialsSecret = getEnv("MAINTENANCE_CREDENTIALS_SECRET", maintenanceCredentialsSecret)
+
+	keepLatestMaintenanceJobsEnv := getEnv("KEEP_LATEST_MAINTENANCE_JOBS", strconv.Itoa(keepLatestMaintenanceJobs))
+	if n, err := strconv.Atoi(keepLatestMaintenanceJobsEnv); err == nil {
+		keepLatestMaintenanceJobs = n
+	} else {
+		logger.WithFields(logrus.Fields{"error": err, "value": keepLatestMaintenanceJobsEnv}).Error("Invalid KEEP_LATEST_MAINTENANCE_JOBS, keeping default")
+	}
+
+	maintenanceCPURequest = getEnv("MAINTENANCE_CPU_REQUEST", maintenanceCPURequest)
+	maintenanceMemoryRequest = getEnv("MAINTENANCE_MEMORY_REQUEST", maintenanceMemoryRequest)
+	maintenanceCPULimit = getEnv("MAINTENANCE_CPU_LIMIT", maintenanceCPULimit)
+	maintenanceMemoryLimit = getEnv("MAINTENANCE_MEMORY_LIMIT", maintenanceMemoryLimit)
+	maintenanceNodeSelector = parseNodeSelector(getEnv("MAINTENANCE_NODE_SELECTOR", ""))
+
+	maintenanceReconcilePeriodEnv := getEnv("MAINTENANCE_RECONCILE_PERIOD", maintenanceReconcilePeriod.String())
+	if d, err := time.ParseDuration(maintenanceReconcilePeriodEnv); err == nil {
+		maintenanceReconcilePeriod = d
+	} else {
+		logger.WithFields(logrus.Fields{"error": err, "value": maintenanceReconcilePeriodEnv}).Error("Invalid MAINTENANCE_RECONCILE_PERIOD, keeping default")
+	}
+
+	selectorConfigMapName = getEnv("SELECTOR_CONFIGMAP_NAME", selectorConfigMapName)
+	selectorConfigMapNSName = getEnv("SELECTOR_CONFIGMAP_NAMESPACE", selectorConfigMapNSName)
+
+	finalBackupTimeoutEnv := getEnv("FINAL_BACKUP_TIMEOUT", finalBackupTimeout.String())
+	if d, err := time.ParseDuration(finalBackupTimeoutEnv); err == nil {
+		finalBackupTimeout = d
+	} else {
+		logger.WithFields(logrus.Fields{"error": err, "value": finalBackupTimeoutEnv}).Error("Invalid FINAL_BACKUP_TIMEOUT, keeping default")
+	}
+
+	if tolerationsEnv := os.Getenv("MAINTENANCE_TOLERATIONS"); tolerationsEnv != "" {
+		var tolerations []corev1.Toleration
+		if err := json.Unmarshal([]byte(tolerationsEnv), &tolerations); err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Invalid MAINTENANCE_TOLERATIONS, expected a JSON array of Toleration")
+		} else {
+			maintenanceTolerations = tolerations
+		}
+	}
+
 	logFormat = getEnv("LOG_FORMAT", "text")
 
 	logLevel = getEnv("LOG_LEVEL", "")
 
 	logger.WithFields(logrus.Fields{
-		"veleroNamespace":          veleroNamespace,
-		"cronExpression":           cronExpression,
-		"csiSnapshotTimeout":       csiSnapshotTimeout,
-		"storageLocation":          storageLocation,
-		"backupTTL":                backupTTL,
-		"defaultVolumesToFsBackup": defaultVolumesToFsBackup,
-		"backupSuffix":             backupSuffix,
-		"logFormat":                logFormat,
-		"logLevel":                 logLevel,
+		"veleroNamespace":           veleroNamespace,
+		"cronExpression":            cronExpression,
+		"csiSnapshotTimeout":        csiSnapshotTimeout,
+		"storageLocation":           storageLocation,
+		"backupTTL":                 backupTTL,
+		"defaultVolumesToFsBackup":  defaultVolumesToFsBackup,
+		"backupSuffix":              backupSuffix,
+		"reconcileResyncPeriod":     reconcileResyncPeriod,
+		"finalBackupTimeout":        finalBackupTimeout,
+		"enableMaintenanceJobs":     enableMaintenanceJobs,
+		"maintenanceCron":           maintenanceCron,
+		"maintenanceImage":          maintenanceImage,
+		"maintenanceServiceAccount": maintenanceServiceAccount,
+		"keepLatestMaintenanceJobs": keepLatestMaintenanceJobs,
+		"selector":                  selectorFlag,
+		"celSelector":               celSelectorFlag,
+		"selectorConfigMapName":     selectorConfigMapName,
+		"logFormat":                 logFormat,
+		"logLevel":                  logLevel,
 	}).Info("Set environment variables")
 
 	if logFormat == "json" {
@@ -359,6 +547,16 @@ func setEnv() {
 	}
 }
 
+// isBackupTarget reports whether a Namespace's labels mark it as a Kubevela
+// backup target, i.e. namespace.oam.dev/target is set and usage.oam.dev/runtime
+// equals "target". It backs legacySelector, the default activeSelector used
+// when no --selector, --cel-selector or SELECTOR_CONFIGMAP is configured.
+func isBackupTarget(labels map[string]string) bool {
+	targetName, targetKey := labels["namespace.oam.dev/target"]
+	runtime, runtimeKey := labels["usage.oam.dev/runtime"]
+	return targetKey && targetName != "" && runtimeKey && runtime == "target"
+}
+
 func getEnv(key string, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -366,10 +564,3 @@ func getEnv(key string, defaultValue string) string {
 	}
 	return value
 }
-
-// ServerHealth returns a 200 OK response to indicate that the webhook server is healthy.
-func ServerHealth(w http.ResponseWriter, r *http.Request) {
-	logrus.WithFields(logrus.Fields{"uri": r.RequestURI}).Debug("Healthy")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ok"))
-}
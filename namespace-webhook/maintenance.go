@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// Repository maintenance configuration, set via setEnv and gated by
+// --enable-maintenance-jobs (see enableMaintenanceJobs in main.go).
+var (
+	maintenanceCron              string              = "@every 24h"            // MAINTENANCE_CRON - cadence on which each repository is pruned/compacted
+	keepLatestMaintenanceJobs    int                 = 3                       // KEEP_LATEST_MAINTENANCE_JOBS - how many completed maintenance Jobs to retain
+	maintenanceImage             string              = "velero/velero:v1.14.1" // MAINTENANCE_IMAGE - pinned image running `velero repo-maintenance`
+	maintenanceServiceAccount    string              = "velero"                // MAINTENANCE_SERVICE_ACCOUNT - SA the maintenance Job runs as, must carry the same RBAC as the Velero server
+	maintenanceCredentialsSecret string              = "cloud-credentials"     // MAINTENANCE_CREDENTIALS_SECRET - Secret mounted at /credentials so repo-maintenance can reach the backup storage location
+	maintenanceCPURequest        string              = "100m"                  // MAINTENANCE_CPU_REQUEST
+	maintenanceMemoryRequest     string              = "128Mi"                 // MAINTENANCE_MEMORY_REQUEST
+	maintenanceCPULimit          string              = "1"                     // MAINTENANCE_CPU_LIMIT
+	maintenanceMemoryLimit       string              = "1Gi"                   // MAINTENANCE_MEMORY_LIMIT
+	maintenanceNodeSelector      map[string]string                             // MAINTENANCE_NODE_SELECTOR - comma-separated key=value pairs
+	maintenanceTolerations       []corev1.Toleration                           // MAINTENANCE_TOLERATIONS - JSON array of corev1.Toleration
+	maintenanceReconcilePeriod   = 10 * time.Minute                            // MAINTENANCE_RECONCILE_PERIOD - how often BackupRepositories are re-scanned
+)
+
+// maintenanceCredentialsMountPath is where the credentials Secret is mounted
+// in the maintenance Job container, matching the path Velero's own server
+// deployment mounts cloud-credentials at.
+const maintenanceCredentialsMountPath = "/credentials"
+
+// backupRepositoryResource identifies Velero's BackupRepository CRD, one
+// object per (namespace, repository type, backup storage location).
+var backupRepositoryResource = schema.GroupVersionResource{
+	Group:    "velero.io",
+	Version:  "v1",
+	Resource: "backuprepositories",
+}
+
+// cronJobResource identifies the built-in batch/v1 CronJob kind used to run
+// repository maintenance on a schedule.
+var cronJobResource = schema.GroupVersionResource{
+	Group:    "batch",
+	Version:  "v1",
+	Resource: "cronjobs",
+}
+
+// startMaintenanceJobController provisions and reconciles one maintenance
+// CronJob per Velero BackupRepository on maintenanceCron, mirroring Velero's
+// own repository maintenance feature but driven by this webhook so operators
+// can tune cadence, retention and scheduling without patching Velero itself.
+// It blocks, so callers should run it in a goroutine; it is a no-op unless
+// --enable-maintenance-jobs was passed.
+func startMaintenanceJobController() error {
+	if !enableMaintenanceJobs {
+		return nil
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return fmt.Errorf("get in-cluster config: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("create dynamic client: %w", err)
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"component": "maintenance"})
+	ctx := context.Background()
+
+	reconcileMaintenanceJobs(ctx, dynamicClient, logger)
+
+	ticker := time.NewTicker(maintenanceReconcilePeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		reconcileMaintenanceJobs(ctx, dynamicClient, logger)
+	}
+
+	return nil
+}
+
+// reconcileMaintenanceJobs ensures every BackupRepository in veleroNamespace
+// has a matching maintenance CronJob, creating or updating it as needed.
+func reconcileMaintenanceJobs(ctx context.Context, client dynamic.Interface, logger *logrus.Entry) {
+	repos, err := client.Resource(backupRepositoryResource).Namespace(veleroNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to list BackupRepositories")
+		return
+	}
+
+	for i := range repos.Items {
+		ensureMaintenanceCronJob(ctx, client, &repos.Items[i], logger)
+	}
+}
+
+// ensureMaintenanceCronJob creates the maintenance CronJob for repo if it is
+// missing, or updates it in place if its schedule, retention or scheduling
+// constraints have drifted from the current configuration.
+func ensureMaintenanceCronJob(ctx context.Context, client dynamic.Interface, repo *unstructured.Unstructured, logger *logrus.Entry) {
+	cronJobName := fmt.Sprintf("%s-maintenance", repo.GetName())
+	repoType, _, _ := unstructured.NestedString(repo.Object, "spec", "repositoryType")
+	storageLocation, _, _ := unstructured.NestedString(repo.Object, "spec", "backupStorageLocation")
+	volumeNamespace, _, _ := unstructured.NestedString(repo.Object, "spec", "volumeNamespace")
+
+	desiredSpec, err := metav1ToUnstructured(maintenanceCronJobSpec(repo.GetName(), repoType, storageLocation))
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "cronjob": cronJobName}).Error("Failed to normalize desired maintenance CronJob spec")
+		return
+	}
+
+	existing, err := client.Resource(cronJobResource).Namespace(veleroNamespace).Get(ctx, cronJobName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cronJob := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "CronJob",
+			"metadata": map[string]interface{}{
+				"name":      cronJobName,
+				"namespace": veleroNamespace,
+				"labels": map[string]interface{}{
+					"velero.io/backup-repository": repo.GetName(),
+				},
+			},
+			"spec": desiredSpec,
+		}}
+		logger.WithFields(logrus.Fields{"repository": repo.GetName(), "namespace": volumeNamespace}).Info("Creating repository maintenance CronJob")
+		if _, err := client.Resource(cronJobResource).Namespace(veleroNamespace).Create(ctx, cronJob, metav1.CreateOptions{}); err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "cronjob": cronJobName}).Error("Failed to create maintenance CronJob")
+		}
+		return
+	}
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "cronjob": cronJobName}).Error("Failed to get maintenance CronJob")
+		return
+	}
+
+	live, _, _ := unstructured.NestedMap(existing.Object, "spec")
+	if reflect.DeepEqual(maintenanceCronJobFingerprint(live), maintenanceCronJobFingerprint(desiredSpec)) {
+		return
+	}
+
+	logger.WithFields(logrus.Fields{"cronjob": cronJobName}).Info("Re-syncing drifted maintenance CronJob spec")
+	if err := unstructured.SetNestedMap(existing.Object, desiredSpec, "spec"); err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "cronjob": cronJobName}).Error("Failed to set desired maintenance CronJob spec")
+		return
+	}
+	if _, err := client.Resource(cronJobResource).Namespace(veleroNamespace).Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "cronjob": cronJobName}).Error("Failed to update maintenance CronJob")
+	}
+}
+
+// maintenanceCronJobSpec builds the batch/v1 CronJobSpec that runs `velero
+// repo-maintenance` for the given repository on maintenanceCron, retaining at
+// most keepLatestMaintenanceJobs completed Jobs.
+func maintenanceCronJobSpec(repoName, repoType, storageLocation string) map[string]interface{} {
+	container := map[string]interface{}{
+		"name":  "repo-maintenance",
+		"image": maintenanceImage,
+		"args": []interface{}{
+			"repo-maintenance",
+			"--repo-name", repoName,
+			"--repo-type", repoType,
+			"--backup-storage-location", storageLocation,
+			"--log-level", logLevel,
+		},
+		// Velero's own server deployment sets all three so the same
+		// credentials Secret works no matter which provider plugin is in
+		// use; repo-maintenance needs the same access to authenticate
+		// against the backup storage location.
+		"env": []interface{}{
+			map[string]interface{}{"name": "AWS_SHARED_CREDENTIALS_FILE", "value": maintenanceCredentialsMountPath + "/cloud"},
+			map[string]interface{}{"name": "GOOGLE_APPLICATION_CREDENTIALS", "value": maintenanceCredentialsMountPath + "/cloud"},
+			map[string]interface{}{"name": "AZURE_CREDENTIALS_FILE", "value": maintenanceCredentialsMountPath + "/cloud"},
+		},
+		"volumeMounts": []interface{}{
+			map[string]interface{}{"name": "cloud-credentials", "mountPath": maintenanceCredentialsMountPath},
+		},
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{
+				"cpu":    maintenanceCPURequest,
+				"memory": maintenanceMemoryRequest,
+			},
+			"limits": map[string]interface{}{
+				"cpu":    maintenanceCPULimit,
+				"memory": maintenanceMemoryLimit,
+			},
+		},
+	}
+
+	podSpec := map[string]interface{}{
+		"restartPolicy":      "OnFailure",
+		"serviceAccountName": maintenanceServiceAccount,
+		"containers":         []interface{}{container},
+		"volumes": []interface{}{
+			map[string]interface{}{
+				"name": "cloud-credentials",
+				"secret": map[string]interface{}{
+					"secretName": maintenanceCredentialsSecret,
+				},
+			},
+		},
+	}
+	if len(maintenanceNodeSelector) > 0 {
+		selector := make(map[string]interface{}, len(maintenanceNodeSelector))
+		for k, v := range maintenanceNodeSelector {
+			selector[k] = v
+		}
+		podSpec["nodeSelector"] = selector
+	}
+	if len(maintenanceTolerations) > 0 {
+		if tolerations, err := toUnstructuredSlice(maintenanceTolerations); err == nil {
+			podSpec["tolerations"] = tolerations
+		}
+	}
+
+	return map[string]interface{}{
+		"schedule":                   maintenanceCron,
+		"successfulJobsHistoryLimit": int64(keepLatestMaintenanceJobs),
+		"failedJobsHistoryLimit":     int64(keepLatestMaintenanceJobs),
+		"jobTemplate": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": podSpec,
+				},
+			},
+		},
+	}
+}
+
+// maintenanceCronJobFingerprint extracts just the fields this controller
+// manages from a CronJob spec, for drift comparison. Comparing the raw spec
+// map directly is unreliable: the API server defaults in fields we never set
+// (concurrencyPolicy, suspend, dnsPolicy, container imagePullPolicy, ...), and
+// JSON-decoded numbers (float64, from desiredSpec's metav1ToUnstructured
+// round-trip) never equal the dynamic client's int64. Normalizing the history
+// limits to int64 and projecting down to only the managed fields keeps the
+// comparison free of both problems.
+func maintenanceCronJobFingerprint(spec map[string]interface{}) map[string]interface{} {
+	fingerprint := map[string]interface{}{
+		"schedule":                   spec["schedule"],
+		"successfulJobsHistoryLimit": nestedJobsHistoryLimit(spec, "successfulJobsHistoryLimit"),
+		"failedJobsHistoryLimit":     nestedJobsHistoryLimit(spec, "failedJobsHistoryLimit"),
+	}
+
+	podSpec, _, _ := unstructured.NestedMap(spec, "jobTemplate", "spec", "template", "spec")
+	fingerprint["serviceAccountName"] = podSpec["serviceAccountName"]
+	fingerprint["nodeSelector"] = podSpec["nodeSelector"]
+	// Round-tripped through JSON so tolerationSeconds is float64 on both sides.
+	fingerprint["tolerations"], _ = toUnstructuredSlice(podSpec["tolerations"])
+
+	// Only each volume's name and secretName are managed; the API server
+	// defaults secret.defaultMode, which desiredSpec never sets.
+	volumes, _, _ := unstructured.NestedSlice(podSpec, "volumes")
+	managedVolumes := make([]interface{}, 0, len(volumes))
+	for _, v := range volumes {
+		volume, _ := v.(map[string]interface{})
+		secretName, _, _ := unstructured.NestedString(volume, "secret", "secretName")
+		managedVolumes = append(managedVolumes, map[string]interface{}{"name": volume["name"], "secretName": secretName})
+	}
+	fingerprint["volumes"] = managedVolumes
+
+	containers, _, _ := unstructured.NestedSlice(podSpec, "containers")
+	if len(containers) > 0 {
+		container, _ := containers[0].(map[string]interface{})
+		fingerprint["image"] = container["image"]
+		fingerprint["args"] = container["args"]
+		fingerprint["env"] = container["env"]
+		fingerprint["volumeMounts"] = container["volumeMounts"]
+		fingerprint["resources"] = container["resources"]
+	}
+
+	return fingerprint
+}
+
+// nestedJobsHistoryLimit reads an int64 history-limit field that may have
+// been decoded as either int64 (values read back from the API server) or
+// float64 (values produced by metav1ToUnstructured's JSON round-trip).
+func nestedJobsHistoryLimit(spec map[string]interface{}, field string) int64 {
+	switch v := spec[field].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	default:
+		return 0
+	}
+}
+
+// toUnstructuredSlice round-trips obj through JSON into a []interface{}, the
+// shape unstructured.Unstructured requires for list fields.
+func toUnstructuredSlice(obj interface{}) ([]interface{}, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parseNodeSelector parses a comma-separated "key=value,key2=value2" string
+// as used by the MAINTENANCE_NODE_SELECTOR environment variable.
+func parseNodeSelector(csv string) map[string]string {
+	if csv == "" {
+		return nil
+	}
+	selector := map[string]string{}
+	for _, pair := range strings.Split(csv, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) == 2 && kv[0] != "" {
+			selector[kv[0]] = kv[1]
+		}
+	}
+	return selector
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Metrics exported on /metrics. Names follow the namespace_webhook_* prefix so
+// they are unambiguous alongside Velero's and the cluster's own metrics.
+var (
+	admissionRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "namespace_webhook_admission_requests_total",
+		Help: "Total admission requests handled, by operation and result.",
+	}, []string{"operation", "result"})
+
+	scheduleOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "namespace_webhook_schedule_operations_total",
+		Help: "Total Velero Schedule create/delete operations, by operation and result.",
+	}, []string{"operation", "result"})
+
+	backupCreateDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "namespace_webhook_backup_create_duration_seconds",
+		Help:    "Time taken to create a Velero Backup object via the dynamic client.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	reconcilerDriftDetectedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "namespace_webhook_reconciler_drift_detected_total",
+		Help: "Total times the reconciler found a Schedule whose spec had drifted from its resolved BackupPolicy.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(admissionRequestsTotal, scheduleOperationsTotal, backupCreateDuration, reconcilerDriftDetectedTotal)
+}
+
+// metricsHandler exposes all registered Prometheus metrics.
+func metricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ServerLivez reports whether the process itself is alive. It never reaches
+// into the cluster, so a slow or unreachable API server cannot make the
+// kubelet restart an otherwise-healthy pod.
+func ServerLivez(w http.ResponseWriter, r *http.Request) {
+	logrus.WithFields(logrus.Fields{"uri": r.RequestURI}).Debug("Live")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// ServerReadyz reports whether the webhook can actually serve admission
+// requests: it must be able to build an in-cluster config and a dynamic
+// client, and the Velero Schedule CRD must be discoverable on the API server.
+func ServerReadyz(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithFields(logrus.Fields{"uri": r.RequestURI})
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Readiness check failed: in-cluster config")
+		http.Error(w, fmt.Sprintf("not ready: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Readiness check failed: dynamic client")
+		http.Error(w, fmt.Sprintf("not ready: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	veleroScheduleResource := scheduleGVR()
+	if _, err := dynamicClient.Resource(veleroScheduleResource).Namespace(veleroNamespace).List(ctx, metav1.ListOptions{Limit: 1}); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Readiness check failed: Velero Schedule CRD not discoverable")
+		http.Error(w, fmt.Sprintf("not ready: Velero Schedule CRD unavailable: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// emitNamespaceEvent records a Kubernetes Event referencing the target
+// Namespace object, filed in eventNamespace. Callers pass the target
+// namespace itself for Create/Update lifecycle events, so they show up
+// under `kubectl describe ns <name>` / `kubectl get events -n <name>` like
+// any other namespace-scoped audit trail; they pass veleroNamespace for
+// Delete-time events, since the API server rejects writes into a namespace
+// that is already terminating ("unable to create new content ... because it
+// is being terminated").
+func emitNamespaceEvent(ctx context.Context, clientset kubernetes.Interface, eventNamespace, namespaceName, reason, message, eventType string, logger *logrus.Entry) {
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-", namespaceName),
+			Namespace:    eventNamespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+			Name:       namespaceName,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: "namespace-webhook"},
+		FirstTimestamp: metav1.Now(),
+		LastTimestamp:  metav1.Now(),
+		Count:          1,
+	}
+
+	if _, err := clientset.CoreV1().Events(eventNamespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "reason": reason, "namespace": namespaceName}).Warn("Failed to emit namespace event")
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Annotation keys read from the target Namespace object. They let an individual
+// namespace override the cluster/namespace-scoped BackupPolicy resolved for it
+// without requiring a new CRD object per namespace.
+const (
+	annotationSchedule          = "backup.policy/schedule"
+	annotationTTL               = "backup.policy/ttl"
+	annotationStorageLocation   = "backup.policy/storage-location"
+	annotationIncludedResources = "backup.policy/included-resources"
+	annotationExcludedResources = "backup.policy/excluded-resources"
+	annotationSnapshotMoveData  = "backup.policy/snapshot-move-data"
+	annotationLabelSelector     = "backup.policy/label-selector"
+	annotationHooks             = "backup.policy/hooks"
+)
+
+// clusterBackupPolicyResource identifies the cluster-scoped CRD that supplies the
+// fleet-wide default backup policy.
+var clusterBackupPolicyResource = schema.GroupVersionResource{
+	Group:    "backup.policy",
+	Version:  "v1alpha1",
+	Resource: "clusterbackuppolicies",
+}
+
+// backupPolicyResource identifies the namespace-scoped CRD that lets a namespace
+// (or the operator on its behalf) override the cluster default.
+var backupPolicyResource = schema.GroupVersionResource{
+	Group:    "backup.policy",
+	Version:  "v1alpha1",
+	Resource: "backuppolicies",
+}
+
+// clusterBackupPolicyGVK and backupPolicyGVK are the Kind-based equivalents of
+// the GroupVersionResources above, needed by controller-runtime clients (such
+// as the reconciler's) that address objects by GroupVersionKind rather than
+// GroupVersionResource.
+var clusterBackupPolicyGVK = schema.GroupVersionKind{Group: "backup.policy", Version: "v1alpha1", Kind: "ClusterBackupPolicy"}
+var backupPolicyGVK = schema.GroupVersionKind{Group: "backup.policy", Version: "v1alpha1", Kind: "BackupPolicy"}
+
+// defaultPolicyName is the well-known object name the webhook looks up: one
+// ClusterBackupPolicy cluster-wide, and optionally one BackupPolicy per namespace.
+const defaultPolicyName = "default"
+
+// BackupPolicySpec mirrors the fields of the Velero Backup/Schedule spec that
+// operators are allowed to tune, either via a BackupPolicy/ClusterBackupPolicy
+// object or via backup.policy/* annotations on the Namespace.
+type BackupPolicySpec struct {
+	Schedule                 string                 `json:"schedule,omitempty"`
+	TTL                      string                 `json:"ttl,omitempty"`
+	StorageLocation          string                 `json:"storageLocation,omitempty"`
+	CSISnapshotTimeout       string                 `json:"csiSnapshotTimeout,omitempty"`
+	IncludedResources        []string               `json:"includedResources,omitempty"`
+	ExcludedResources        []string               `json:"excludedResources,omitempty"`
+	LabelSelector            *metav1.LabelSelector  `json:"labelSelector,omitempty"`
+	SnapshotMoveData         *bool                  `json:"snapshotMoveData,omitempty"`
+	DefaultVolumesToFsBackup *bool                  `json:"defaultVolumesToFsBackup,omitempty"`
+	Hooks                    map[string]interface{} `json:"hooks,omitempty"`
+}
+
+// defaultBackupPolicy returns the built-in defaults, sourced from the same
+// package-level variables the webhook has always used so that a cluster with no
+// BackupPolicy objects at all keeps behaving exactly as before.
+func defaultBackupPolicy() BackupPolicySpec {
+	fsBackup := defaultVolumesToFsBackup
+	return BackupPolicySpec{
+		Schedule:                 cronExpression,
+		TTL:                      backupTTL,
+		StorageLocation:          storageLocation,
+		CSISnapshotTimeout:       csiSnapshotTimeout,
+		DefaultVolumesToFsBackup: &fsBackup,
+	}
+}
+
+// resolveBackupPolicy builds the effective BackupPolicySpec for namespaceName by
+// layering, from lowest to highest precedence: built-in defaults, the cluster-wide
+// ClusterBackupPolicy "default", the namespace-scoped BackupPolicy "default" in
+// namespaceName, and finally backup.policy/* annotations on the Namespace object.
+func resolveBackupPolicy(ctx context.Context, client dynamic.Interface, namespaceName string, annotations map[string]string, logger *logrus.Entry) BackupPolicySpec {
+	policy := defaultBackupPolicy()
+
+	if cluster, err := client.Resource(clusterBackupPolicyResource).Get(ctx, defaultPolicyName, metav1.GetOptions{}); err == nil {
+		mergeBackupPolicySpec(&policy, cluster, logger)
+	} else {
+		logger.WithFields(logrus.Fields{"error": err}).Debug("No cluster-wide ClusterBackupPolicy found, using defaults")
+	}
+
+	if namespaced, err := client.Resource(backupPolicyResource).Namespace(namespaceName).Get(ctx, defaultPolicyName, metav1.GetOptions{}); err == nil {
+		mergeBackupPolicySpec(&policy, namespaced, logger)
+	} else {
+		logger.WithFields(logrus.Fields{"error": err}).Debug("No namespace-scoped BackupPolicy found")
+	}
+
+	applyAnnotationOverrides(&policy, annotations, logger)
+
+	return policy
+}
+
+// mergeBackupPolicySpec decodes obj's spec into a BackupPolicySpec and overlays
+// any field it sets onto policy, leaving fields it omits untouched.
+func mergeBackupPolicySpec(policy *BackupPolicySpec, obj *unstructured.Unstructured, logger *logrus.Entry) {
+	specRaw, found, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil || !found {
+		return
+	}
+
+	raw, err := json.Marshal(specRaw)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "object": obj.GetName()}).Warn("Failed to marshal BackupPolicy spec")
+		return
+	}
+
+	var override BackupPolicySpec
+	if err := json.Unmarshal(raw, &override); err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "object": obj.GetName()}).Warn("Failed to parse BackupPolicy spec")
+		return
+	}
+
+	if override.Schedule != "" {
+		policy.Schedule = override.Schedule
+	}
+	if override.TTL != "" {
+		policy.TTL = override.TTL
+	}
+	if override.StorageLocation != "" {
+		policy.StorageLocation = override.StorageLocation
+	}
+	if override.CSISnapshotTimeout != "" {
+		policy.CSISnapshotTimeout = override.CSISnapshotTimeout
+	}
+	if len(override.IncludedResources) > 0 {
+		policy.IncludedResources = override.IncludedResources
+	}
+	if len(override.ExcludedResources) > 0 {
+		policy.ExcludedResources = override.ExcludedResources
+	}
+	if override.LabelSelector != nil {
+		policy.LabelSelector = override.LabelSelector
+	}
+	if override.SnapshotMoveData != nil {
+		policy.SnapshotMoveData = override.SnapshotMoveData
+	}
+	if override.DefaultVolumesToFsBackup != nil {
+		policy.DefaultVolumesToFsBackup = override.DefaultVolumesToFsBackup
+	}
+	if len(override.Hooks) > 0 {
+		policy.Hooks = override.Hooks
+	}
+}
+
+// applyAnnotationOverrides reads backup.policy/* annotations from the Namespace
+// object and, for any that are present, overrides the corresponding policy field.
+// Annotations always win over BackupPolicy/ClusterBackupPolicy objects so that an
+// operator can patch a single namespace without touching shared CRD state.
+func applyAnnotationOverrides(policy *BackupPolicySpec, annotations map[string]string, logger *logrus.Entry) {
+	if v, ok := annotations[annotationSchedule]; ok && v != "" {
+		policy.Schedule = v
+	}
+	if v, ok := annotations[annotationTTL]; ok && v != "" {
+		policy.TTL = v
+	}
+	if v, ok := annotations[annotationStorageLocation]; ok && v != "" {
+		policy.StorageLocation = v
+	}
+	if v, ok := annotations[annotationIncludedResources]; ok && v != "" {
+		policy.IncludedResources = splitAndTrim(v)
+	}
+	if v, ok := annotations[annotationExcludedResources]; ok && v != "" {
+		policy.ExcludedResources = splitAndTrim(v)
+	}
+	if v, ok := annotations[annotationSnapshotMoveData]; ok && v != "" {
+		if moveData, err := strconv.ParseBool(v); err == nil {
+			policy.SnapshotMoveData = &moveData
+		} else {
+			logger.WithFields(logrus.Fields{"error": err, "value": v}).Warn("Invalid backup.policy/snapshot-move-data annotation")
+		}
+	}
+	if v, ok := annotations[annotationLabelSelector]; ok && v != "" {
+		selector, err := metav1.ParseToLabelSelector(v)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "value": v}).Warn("Invalid backup.policy/label-selector annotation")
+		} else {
+			policy.LabelSelector = selector
+		}
+	}
+	if v, ok := annotations[annotationHooks]; ok && v != "" {
+		var hooks map[string]interface{}
+		if err := json.Unmarshal([]byte(v), &hooks); err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "value": v}).Warn("Invalid backup.policy/hooks annotation, expected JSON")
+		} else {
+			policy.Hooks = hooks
+		}
+	}
+}
+
+func splitAndTrim(csv string) []string {
+	parts := strings.Split(csv, ",")
+	result := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// backupTemplate renders policy as the spec map shared by both the Velero
+// Schedule's template and an instant Backup, so the two never drift apart.
+func backupTemplate(namespaceName string, policy BackupPolicySpec) map[string]interface{} {
+	template := map[string]interface{}{
+		"csiSnapshotTimeout": policy.CSISnapshotTimeout,
+		"includedNamespaces": []string{namespaceName},
+		"storageLocation":    policy.StorageLocation,
+		"ttl":                policy.TTL,
+	}
+
+	if policy.DefaultVolumesToFsBackup != nil {
+		template["defaultVolumesToFsBackup"] = *policy.DefaultVolumesToFsBackup
+	}
+	if len(policy.IncludedResources) > 0 {
+		template["includedResources"] = policy.IncludedResources
+	}
+	if len(policy.ExcludedResources) > 0 {
+		template["excludedResources"] = policy.ExcludedResources
+	}
+	if policy.SnapshotMoveData != nil {
+		template["snapshotMoveData"] = *policy.SnapshotMoveData
+	}
+	if policy.LabelSelector != nil {
+		selector, err := metav1ToUnstructured(policy.LabelSelector)
+		if err == nil {
+			template["labelSelector"] = selector
+		}
+	}
+	if len(policy.Hooks) > 0 {
+		template["hooks"] = policy.Hooks
+	}
+
+	return template
+}
+
+// metav1ToUnstructured round-trips a typed object through JSON into the
+// map[string]interface{} shape unstructured.Unstructured requires.
+func metav1ToUnstructured(obj interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return out, nil
+}
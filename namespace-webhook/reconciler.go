@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// veleroScheduleGVK identifies the Velero Schedule kind the reconciler watches
+// and repairs.
+var veleroScheduleGVK = schema.GroupVersionKind{Group: "velero.io", Version: "v1", Kind: "Schedule"}
+
+// reconcileResyncPeriod is how often the reconciler re-lists every namespace
+// and Schedule, in addition to reacting to individual namespace watch events.
+// The full re-list is what catches drift and orphans that accumulated while
+// the webhook (and this reconciler) were not running to see the events.
+var reconcileResyncPeriod = 5 * time.Minute
+
+// startScheduleReconciler runs a watch-based reconciliation loop that keeps
+// Velero Schedules in sync with Namespace labels, closing the gap left by the
+// webhook only reacting to admission requests it was actually sent. It blocks
+// until the namespace watch fails, so callers should run it in a goroutine.
+func startScheduleReconciler() error {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return fmt.Errorf("get in-cluster config: %w", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return fmt.Errorf("register core scheme: %w", err)
+	}
+
+	watchClient, err := client.NewWithWatch(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("create watch client: %w", err)
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"component": "reconciler"})
+	ctx := context.Background()
+
+	watcher, err := watchClient.Watch(ctx, &corev1.NamespaceList{})
+	if err != nil {
+		return fmt.Errorf("watch namespaces: %w", err)
+	}
+	defer watcher.Stop()
+
+	// Reconcile every known namespace once at startup so that Schedules for
+	// pre-existing target namespaces and orphaned Schedules for namespaces
+	// deleted while the webhook was down are both repaired immediately.
+	reconcileAllNamespaces(ctx, watchClient, logger)
+
+	resync := time.NewTicker(reconcileResyncPeriod)
+	defer resync.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return fmt.Errorf("namespace watch channel closed")
+			}
+			ns, ok := event.Object.(*corev1.Namespace)
+			if !ok {
+				continue
+			}
+			reconcileNamespace(ctx, watchClient, ns.Name, logger)
+		case <-resync.C:
+			reconcileAllNamespaces(ctx, watchClient, logger)
+		}
+	}
+}
+
+// reconcileAllNamespaces reconciles every namespace in the cluster and then
+// prunes any Schedule left behind by a namespace that is gone or no longer a
+// target.
+func reconcileAllNamespaces(ctx context.Context, c client.WithWatch, logger *logrus.Entry) {
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to list namespaces for reconciliation")
+		return
+	}
+
+	targets := make(map[string]bool, len(namespaces.Items))
+	for i := range namespaces.Items {
+		ns := namespaces.Items[i]
+		targets[ns.Name] = activeSelector.Matches(ns, corev1.Namespace{}, "Reconcile")
+		reconcileNamespace(ctx, c, ns.Name, logger)
+	}
+
+	pruneOrphanSchedules(ctx, c, targets, logger)
+}
+
+// reconcileNamespace ensures the Schedule for namespaceName matches what the
+// resolved BackupPolicy says it should be, creating, updating or deleting it
+// as needed.
+func reconcileNamespace(ctx context.Context, c client.WithWatch, namespaceName string, logger *logrus.Entry) {
+	var ns corev1.Namespace
+	err := c.Get(ctx, client.ObjectKey{Name: namespaceName}, &ns)
+	if apierrors.IsNotFound(err) {
+		deleteScheduleIfExists(ctx, c, namespaceName, logger)
+		return
+	}
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "namespace": namespaceName}).Error("Failed to get namespace for reconciliation")
+		return
+	}
+
+	if !activeSelector.Matches(ns, corev1.Namespace{}, "Reconcile") {
+		deleteScheduleIfExists(ctx, c, namespaceName, logger)
+		return
+	}
+
+	policy := resolveBackupPolicyFromClient(ctx, c, namespaceName, ns.Annotations, logger)
+	ensureSchedule(ctx, c, namespaceName, policy, logger)
+}
+
+// pruneOrphanSchedules deletes every Schedule in veleroNamespace whose target
+// namespace is missing from targets or is no longer marked as a target.
+func pruneOrphanSchedules(ctx context.Context, c client.WithWatch, targets map[string]bool, logger *logrus.Entry) {
+	var schedules unstructured.UnstructuredList
+	schedules.SetGroupVersionKind(veleroScheduleGVK)
+	if err := c.List(ctx, &schedules, client.InNamespace(veleroNamespace)); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Failed to list Velero schedules for reconciliation")
+		return
+	}
+
+	for i := range schedules.Items {
+		schedule := schedules.Items[i]
+		namespaceName := strings.TrimSuffix(schedule.GetName(), "-backup")
+		if targets[namespaceName] {
+			continue
+		}
+
+		logger.WithFields(logrus.Fields{"schedule": schedule.GetName()}).Info("Deleting orphaned Velero schedule")
+		if err := c.Delete(ctx, &schedule); err != nil && !apierrors.IsNotFound(err) {
+			logger.WithFields(logrus.Fields{"error": err, "schedule": schedule.GetName()}).Error("Failed to delete orphaned Velero schedule")
+		}
+	}
+}
+
+// ensureSchedule creates the Schedule for namespaceName if it is missing, or
+// re-syncs its spec if it has drifted from what policy now resolves to.
+func ensureSchedule(ctx context.Context, c client.WithWatch, namespaceName string, policy BackupPolicySpec, logger *logrus.Entry) {
+	scheduleName := fmt.Sprintf("%s-backup", namespaceName)
+	// Round-trip through JSON so desired only contains the JSON-native types
+	// (map[string]interface{}, []interface{}, ...) that unstructured content
+	// read back from the API server decodes into; backupTemplate itself
+	// returns Go slices like []string, and comparing those directly against
+	// live would always report drift, and handing them to SetNestedMap
+	// panics because it cannot deep-copy a []string.
+	desired, err := metav1ToUnstructured(map[string]interface{}{
+		"schedule":                   policy.Schedule,
+		"useOwnerReferencesInBackup": false,
+		"template":                   backupTemplate(namespaceName, policy),
+	})
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "schedule": scheduleName}).Error("Failed to normalize desired schedule spec")
+		return
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(veleroScheduleGVK)
+	err = c.Get(ctx, client.ObjectKey{Name: scheduleName, Namespace: veleroNamespace}, existing)
+	if apierrors.IsNotFound(err) {
+		schedule := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "velero.io/v1",
+			"kind":       "Schedule",
+			"metadata": map[string]interface{}{
+				"name":      scheduleName,
+				"namespace": veleroNamespace,
+			},
+			"spec": desired,
+		}}
+		logger.WithFields(logrus.Fields{"schedule": scheduleName}).Info("Creating missing Velero schedule")
+		if err := c.Create(ctx, schedule); err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "schedule": scheduleName}).Error("Failed to create Velero schedule")
+		}
+		return
+	}
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "schedule": scheduleName}).Error("Failed to get Velero schedule for drift detection")
+		return
+	}
+
+	live, _, _ := unstructured.NestedMap(existing.Object, "spec")
+	if reflect.DeepEqual(live, desired) {
+		return
+	}
+
+	reconcilerDriftDetectedTotal.Inc()
+	logger.WithFields(logrus.Fields{"schedule": scheduleName}).Info("Re-syncing drifted Velero schedule spec")
+	if err := unstructured.SetNestedMap(existing.Object, desired, "spec"); err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "schedule": scheduleName}).Error("Failed to set desired schedule spec")
+		return
+	}
+	if err := c.Update(ctx, existing); err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "schedule": scheduleName}).Error("Failed to update drifted Velero schedule")
+	}
+}
+
+// deleteScheduleIfExists deletes the Schedule for namespaceName, if any exists.
+func deleteScheduleIfExists(ctx context.Context, c client.WithWatch, namespaceName string, logger *logrus.Entry) {
+	scheduleName := fmt.Sprintf("%s-backup", namespaceName)
+	schedule := &unstructured.Unstructured{}
+	schedule.SetGroupVersionKind(veleroScheduleGVK)
+	schedule.SetName(scheduleName)
+	schedule.SetNamespace(veleroNamespace)
+
+	if err := c.Delete(ctx, schedule); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.WithFields(logrus.Fields{"error": err, "schedule": scheduleName}).Error("Failed to delete orphaned Velero schedule")
+		}
+		return
+	}
+	logger.WithFields(logrus.Fields{"schedule": scheduleName}).Info("Deleted orphaned Velero schedule")
+}
+
+// resolveBackupPolicyFromClient mirrors resolveBackupPolicy but reads the
+// ClusterBackupPolicy/BackupPolicy objects through the reconciler's
+// controller-runtime client instead of the webhook's dynamic client.
+func resolveBackupPolicyFromClient(ctx context.Context, c client.WithWatch, namespaceName string, annotations map[string]string, logger *logrus.Entry) BackupPolicySpec {
+	policy := defaultBackupPolicy()
+
+	cluster := &unstructured.Unstructured{}
+	cluster.SetGroupVersionKind(clusterBackupPolicyGVK)
+	if err := c.Get(ctx, client.ObjectKey{Name: defaultPolicyName}, cluster); err == nil {
+		mergeBackupPolicySpec(&policy, cluster, logger)
+	}
+
+	namespaced := &unstructured.Unstructured{}
+	namespaced.SetGroupVersionKind(backupPolicyGVK)
+	if err := c.Get(ctx, client.ObjectKey{Name: defaultPolicyName, Namespace: namespaceName}, namespaced); err == nil {
+		mergeBackupPolicySpec(&policy, namespaced, logger)
+	}
+
+	applyAnnotationOverrides(&policy, annotations, logger)
+
+	return policy
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Selector-related configuration. Exactly one of these, in this precedence
+// order, decides which namespaces the webhook treats as backup targets:
+// a ConfigMap (highest precedence), then --cel-selector, then --selector,
+// falling back to the legacy hard-coded Kubevela OAM labels if none are set.
+var (
+	selectorFlag            string // --selector, a Kubernetes label selector expression (e.g. "backup/enabled=true,tier!=ephemeral")
+	celSelectorFlag         string // --cel-selector, a CEL expression evaluated against namespace/oldNamespace/operation
+	selectorConfigMapName   string // SELECTOR_CONFIGMAP_NAME - name of a ConfigMap holding "labelSelector" and/or "celExpression" keys
+	selectorConfigMapNSName string // SELECTOR_CONFIGMAP_NAMESPACE - namespace of that ConfigMap
+
+	// activeSelector is the selector actually used to decide whether a
+	// namespace is a backup target. It defaults to the legacy OAM label check
+	// so clusters that configure nothing keep their current behavior.
+	activeSelector targetSelector = legacySelector{}
+)
+
+// targetSelector decides whether a namespace should be treated as a backup
+// target. oldNamespace and operation give CEL-based selectors the same
+// context an admission webhook has; simpler selectors ignore them.
+//
+// MatchedBefore answers the same question for the namespace's prior state
+// (oldNamespace), as seen by a Create/Update/Delete admission request. It is
+// a distinct method rather than Matches called with its arguments swapped,
+// because for a CEL selector swapping namespace and oldNamespace also flips
+// what any "oldNamespace" reference in the expression sees.
+type targetSelector interface {
+	Matches(namespace, oldNamespace corev1.Namespace, operation string) bool
+	MatchedBefore(namespace, oldNamespace corev1.Namespace, operation string) bool
+}
+
+// legacySelector reproduces the webhook's original hard-coded behavior:
+// namespace.oam.dev/target set and usage.oam.dev/runtime=target.
+type legacySelector struct{}
+
+func (legacySelector) Matches(namespace, _ corev1.Namespace, _ string) bool {
+	return isBackupTarget(namespace.Labels)
+}
+
+func (legacySelector) MatchedBefore(_, oldNamespace corev1.Namespace, _ string) bool {
+	return isBackupTarget(oldNamespace.Labels)
+}
+
+// k8sLabelSelector matches a namespace's labels against a parsed
+// labels.Selector, e.g. loaded from --selector or a ConfigMap.
+type k8sLabelSelector struct {
+	selector labels.Selector
+}
+
+func (s k8sLabelSelector) Matches(namespace, _ corev1.Namespace, _ string) bool {
+	return s.selector.Matches(labels.Set(namespace.Labels))
+}
+
+func (s k8sLabelSelector) MatchedBefore(_, oldNamespace corev1.Namespace, _ string) bool {
+	return s.selector.Matches(labels.Set(oldNamespace.Labels))
+}
+
+// celTargetSelector matches a namespace by evaluating a compiled CEL program
+// against namespace, oldNamespace and operation.
+type celTargetSelector struct {
+	program cel.Program
+	logger  *logrus.Entry
+}
+
+func (s celTargetSelector) Matches(namespace, oldNamespace corev1.Namespace, operation string) bool {
+	return s.eval(namespace, oldNamespace, operation)
+}
+
+// MatchedBefore evaluates the same CEL expression with "namespace" bound to
+// the prior state: unlike Matches(oldNamespace, namespace, operation), it
+// does not also rebind "oldNamespace" to the new object, so a rule that
+// reads oldNamespace keeps seeing the actual prior state in both evaluations.
+func (s celTargetSelector) MatchedBefore(_, oldNamespace corev1.Namespace, operation string) bool {
+	return s.eval(oldNamespace, oldNamespace, operation)
+}
+
+func (s celTargetSelector) eval(namespace, oldNamespace corev1.Namespace, operation string) bool {
+	out, _, err := s.program.Eval(map[string]interface{}{
+		"namespace":    namespaceToCELValue(namespace),
+		"oldNamespace": namespaceToCELValue(oldNamespace),
+		"operation":    operation,
+	})
+	if err != nil {
+		s.logger.WithFields(logrus.Fields{"error": err, "namespace": namespace.Name}).Warn("CEL selector evaluation failed, treating namespace as not a target")
+		return false
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		s.logger.WithFields(logrus.Fields{"namespace": namespace.Name}).Warn("CEL selector did not return a bool, treating namespace as not a target")
+		return false
+	}
+	return matched
+}
+
+// namespaceToCELValue projects a Namespace down to the fields a CEL
+// expression can usefully test: name, labels and annotations.
+func namespaceToCELValue(namespace corev1.Namespace) map[string]interface{} {
+	labelsMap := map[string]interface{}{}
+	for k, v := range namespace.Labels {
+		labelsMap[k] = v
+	}
+	annotationsMap := map[string]interface{}{}
+	for k, v := range namespace.Annotations {
+		annotationsMap[k] = v
+	}
+	return map[string]interface{}{
+		"name":        namespace.Name,
+		"labels":      labelsMap,
+		"annotations": annotationsMap,
+	}
+}
+
+// celEnv is the CEL environment shared by every compiled selector: it exposes
+// namespace, oldNamespace (both maps of name/labels/annotations) and operation.
+func celEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("namespace", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("oldNamespace", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("operation", cel.StringType),
+	)
+}
+
+// compileCELSelector compiles expr into a targetSelector, or returns an error
+// if it doesn't parse or doesn't type-check to a bool.
+func compileCELSelector(expr string, logger *logrus.Entry) (targetSelector, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, fmt.Errorf("build CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("compile CEL expression: %w", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("CEL expression must evaluate to bool, got %s", ast.OutputType())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("build CEL program: %w", err)
+	}
+
+	return celTargetSelector{program: program, logger: logger}, nil
+}
+
+// compileLabelSelector parses expr (kubectl-style "key=value,key2!=value2")
+// into a targetSelector.
+func compileLabelSelector(expr string) (targetSelector, error) {
+	selector, err := labels.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("parse label selector: %w", err)
+	}
+	return k8sLabelSelector{selector: selector}, nil
+}
+
+// loadSelector builds activeSelector from, in precedence order, the
+// SELECTOR_CONFIGMAP, --cel-selector and --selector configuration, falling
+// back to the legacy OAM label selector if none are set. It is safe to call
+// even when selectorConfigMapName is set but the cluster/ConfigMap is
+// unreachable: the webhook logs an error and falls back to --cel-selector,
+// --selector or the legacy selector, in that order.
+func loadSelector(logger *logrus.Entry) {
+	if selectorConfigMapName != "" {
+		if selector, err := loadSelectorFromConfigMap(logger); err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Failed to load selector from ConfigMap, falling back to flags/defaults")
+		} else if selector != nil {
+			activeSelector = selector
+			return
+		}
+	}
+
+	if celSelectorFlag != "" {
+		selector, err := compileCELSelector(celSelectorFlag, logger)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Invalid --cel-selector, falling back to defaults")
+		} else {
+			activeSelector = selector
+			return
+		}
+	}
+
+	if selectorFlag != "" {
+		selector, err := compileLabelSelector(selectorFlag)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Invalid --selector, falling back to defaults")
+		} else {
+			activeSelector = selector
+			return
+		}
+	}
+
+	activeSelector = legacySelector{}
+}
+
+// loadSelectorFromConfigMap fetches selectorConfigMapName/selectorConfigMapNSName
+// and compiles whichever of its "celExpression" or "labelSelector" keys is
+// set, preferring celExpression.
+func loadSelectorFromConfigMap(logger *logrus.Entry) (targetSelector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("get in-cluster config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("create clientset: %w", err)
+	}
+
+	namespace := selectorConfigMapNSName
+	if namespace == "" {
+		namespace = veleroNamespace
+	}
+
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), selectorConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get ConfigMap %s/%s: %w", namespace, selectorConfigMapName, err)
+	}
+
+	if expr := strings.TrimSpace(configMap.Data["celExpression"]); expr != "" {
+		return compileCELSelector(expr, logger)
+	}
+	if expr := strings.TrimSpace(configMap.Data["labelSelector"]); expr != "" {
+		return compileLabelSelector(expr)
+	}
+
+	return nil, fmt.Errorf("ConfigMap %s/%s has neither celExpression nor labelSelector set", namespace, selectorConfigMapName)
+}